@@ -0,0 +1,92 @@
+package main
+
+import (
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// TestParseFileGeneratesBugStubAndConsumesPragma verifies that, with
+// -genBugs set, a func carrying the "//gocmt:bug" pragma gets a synthesized
+// BUG(TODO) stub, and the pragma itself is consumed rather than printed
+// alongside it.
+func TestParseFileGeneratesBugStubAndConsumesPragma(t *testing.T) {
+	*genBugs = true
+	defer func() { *genBugs = false }()
+
+	const src = `package testpkg
+
+//gocmt:bug
+func Foo() {}
+`
+	path := writeTempFile(t, "p.go", src)
+	fset := token.NewFileSet()
+
+	af, modified, err := parseFile(fset, path, " does a thing.\n")
+	if err != nil {
+		t.Fatalf("parseFile: %v", err)
+	}
+	if !modified {
+		t.Errorf("parseFile reported modified = false, want true")
+	}
+
+	out := render(t, fset, af)
+	if strings.Contains(out, "//gocmt:bug") {
+		t.Errorf("parseFile left the //gocmt:bug pragma in the output, output:\n%s", out)
+	}
+	if !strings.Contains(out, "// BUG(TODO): describe Foo") {
+		t.Errorf("parseFile did not synthesize a BUG stub for Foo, output:\n%s", out)
+	}
+	if !strings.Contains(out, "// Foo does a thing.") {
+		t.Errorf("parseFile did not also add Foo's own doc stub, output:\n%s", out)
+	}
+}
+
+// TestParseFileLeavesBugPragmaWithoutFlag verifies that the "//gocmt:bug"
+// pragma is left as an ordinary comment when -genBugs isn't set.
+func TestParseFileLeavesBugPragmaWithoutFlag(t *testing.T) {
+	const src = `package testpkg
+
+//gocmt:bug
+func Foo() {}
+`
+	path := writeTempFile(t, "p.go", src)
+	fset := token.NewFileSet()
+
+	af, _, err := parseFile(fset, path, " does a thing.\n")
+	if err != nil {
+		t.Fatalf("parseFile: %v", err)
+	}
+
+	out := render(t, fset, af)
+	if !strings.Contains(out, "//gocmt:bug") {
+		t.Errorf("parseFile dropped the //gocmt:bug pragma when -genBugs wasn't set, output:\n%s", out)
+	}
+	if strings.Contains(out, "BUG(TODO)") {
+		t.Errorf("parseFile synthesized a BUG stub without -genBugs set, output:\n%s", out)
+	}
+}
+
+// TestFindBugCommentsPreservesExistingBugDoc verifies that an existing
+// BUG(who): comment survives parseFile untouched, restored after
+// ast.CommentMap.Filter drops it as unowned by any surviving decl.
+func TestFindBugCommentsPreservesExistingBugDoc(t *testing.T) {
+	const src = `package testpkg
+
+// BUG(jdoe): this leaks a goroutine.
+
+func Foo() {}
+`
+	path := writeTempFile(t, "p.go", src)
+	fset := token.NewFileSet()
+
+	af, _, err := parseFile(fset, path, " does a thing.\n")
+	if err != nil {
+		t.Fatalf("parseFile: %v", err)
+	}
+
+	out := render(t, fset, af)
+	if !strings.Contains(out, "BUG(jdoe): this leaks a goroutine.") {
+		t.Errorf("parseFile dropped an existing BUG(who) comment, output:\n%s", out)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"go/ast"
+	"regexp"
+)
+
+// directiveRE matches the magic comments the Go toolchain (and common
+// linters) attach meaning to: build constraints, go:generate/go:embed,
+// //line, //nolint, and cgo's preprocessor preamble lines. parseFile must
+// never treat these as candidate doc comments to rewrite, and must never
+// disturb the blank-line separation some of them require from what follows.
+// The +build and cgo alternatives are deliberately narrow (full-line forms
+// with only tag/preprocessor syntax after the marker) so an ordinary doc
+// comment that happens to start with "+build" or "#" isn't misclassified.
+var directiveRE = regexp.MustCompile(`^//(go:build\b|go:generate\b|go:embed\b|line\b|nolint\b|\s*\+build\s+[\w.,!/]+$|\s*#\s*(include|define|undef|if|ifdef|ifndef|else|elif|endif|pragma)\b)`)
+
+// isDirectiveComment reports whether every line of cg is a directive
+// comment, meaning cg as a whole must be left exactly as parsed.
+func isDirectiveComment(cg *ast.CommentGroup) bool {
+	if cg == nil || len(cg.List) == 0 {
+		return false
+	}
+	for _, c := range cg.List {
+		if !directiveRE.MatchString(c.Text) {
+			return false
+		}
+	}
+	return true
+}
+
+// findDirectiveComments returns the directive comment groups in af.Comments,
+// so they can be restored verbatim after cmap.Filter, at their original
+// token.Pos, the same way findBugComments preserves BUG(...) groups.
+func findDirectiveComments(af *ast.File) []*ast.CommentGroup {
+	var directives []*ast.CommentGroup
+	for _, cg := range af.Comments {
+		if isDirectiveComment(cg) {
+			directives = append(directives, cg)
+		}
+	}
+	return directives
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// TestParseFileCommentsExportedFieldsAndMethods verifies that, with -fields
+// and -methods set, parseFile adds a doc comment to each exported struct
+// field and interface method, but leaves unexported ones untouched.
+func TestParseFileCommentsExportedFieldsAndMethods(t *testing.T) {
+	*fieldsComment = true
+	*methodsComment = true
+	defer func() { *fieldsComment = false; *methodsComment = false }()
+
+	const src = `package testpkg
+
+type T struct {
+	Foo int
+	bar int
+}
+
+type I interface {
+	Foo()
+	bar()
+}
+`
+	path := writeTempFile(t, "p.go", src)
+	fset := token.NewFileSet()
+
+	af, modified, err := parseFile(fset, path, " does a thing.\n")
+	if err != nil {
+		t.Fatalf("parseFile: %v", err)
+	}
+	if !modified {
+		t.Errorf("parseFile reported modified = false, want true")
+	}
+
+	out := render(t, fset, af)
+	if !strings.Contains(out, "// Foo does a thing.\n\tFoo\tint") {
+		t.Errorf("parseFile did not add a doc comment to exported field Foo, output:\n%s", out)
+	}
+	if strings.Contains(out, "// bar") {
+		t.Errorf("parseFile added a doc comment to unexported field bar, output:\n%s", out)
+	}
+	if !strings.Contains(out, "// Foo does a thing.\n\tFoo()") {
+		t.Errorf("parseFile did not add a doc comment to exported method Foo, output:\n%s", out)
+	}
+	if strings.Contains(out, "bar()\n") && strings.Contains(out, "// bar does a thing.") {
+		t.Errorf("parseFile added a doc comment to unexported method bar, output:\n%s", out)
+	}
+}
+
+// TestParseFileSkipsFieldsWithoutFlags verifies that parseFile leaves
+// struct fields and interface methods alone when -fields and -methods
+// aren't set, even though the containing type itself still gets a stub.
+func TestParseFileSkipsFieldsWithoutFlags(t *testing.T) {
+	const src = `package testpkg
+
+type T struct {
+	Foo int
+}
+`
+	path := writeTempFile(t, "p.go", src)
+	fset := token.NewFileSet()
+
+	af, _, err := parseFile(fset, path, " does a thing.\n")
+	if err != nil {
+		t.Fatalf("parseFile: %v", err)
+	}
+
+	out := render(t, fset, af)
+	if strings.Contains(out, "// Foo") {
+		t.Errorf("parseFile added a doc comment to field Foo without -fields set, output:\n%s", out)
+	}
+	if !strings.Contains(out, "// T does a thing.") {
+		t.Errorf("parseFile did not add a doc stub to T itself, output:\n%s", out)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"os"
+)
+
+// commentBase is the placeholder gocmt's templates format a declaration's
+// name into, e.g. commentBase+" does a thing.\n" -> "// Foo does a thing.\n".
+const commentBase = "// %s"
+
+var (
+	template          = flag.String("template", " does a thing.\n", "text appended after the declaration's name when synthesizing a doc comment")
+	parenComment      = flag.Bool("parenComment", false, "add a doc comment to each entry of a parenthesized var/const block, not just the block itself")
+	fieldsComment     = flag.Bool("fields", false, "add doc comments to exported struct fields")
+	methodsComment    = flag.Bool("methods", false, "add doc comments to exported interface methods")
+	genBugs           = flag.Bool("genBugs", false, "synthesize a BUG(TODO) stub for any func carrying the //gocmt:bug pragma")
+	signatureComments = flag.Bool("signatureComments", false, "describe funcs from their signature instead of using -template")
+	genCmd            = flag.String("genCmd", "", "shell out to this command to generate doc comment text instead of -template")
+)
+
+func main() {
+	flag.Parse()
+	for _, path := range flag.Args() {
+		if err := processFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "gocmt: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// processFile runs parseFile against path and, if it was modified, writes the
+// result back in place.
+func processFile(path string) error {
+	fset := token.NewFileSet()
+	af, modified, err := parseFile(fset, path, *template)
+	if err != nil {
+		return err
+	}
+	if !modified {
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return printer.Fprint(f, fset, af)
+}
+
+// appendCommentGroup appends doc to existing if doc is non-nil and not
+// already present, so cmap[node] reflects a freshly-assigned Doc even when
+// cmap was built before the rewrite added it.
+func appendCommentGroup(existing []*ast.CommentGroup, doc *ast.CommentGroup) []*ast.CommentGroup {
+	if doc == nil {
+		return existing
+	}
+	for _, cg := range existing {
+		if cg == doc {
+			return existing
+		}
+	}
+	return append(existing, doc)
+}
+
+// isLineComment reports whether cg is made up of "//" comments, as opposed to
+// a single "/* ... */" block comment.
+func isLineComment(cg *ast.CommentGroup) bool {
+	return len(cg.List) > 0 && cg.List[0].Text[1] == '/'
+}
+
+// hasCommentPrefix reports whether cg's text already starts with name, the
+// convention godoc expects ("Foo does a thing.", not "does a thing.").
+func hasCommentPrefix(cg *ast.CommentGroup, name string) bool {
+	text := cg.Text()
+	return len(text) >= len(name) && text[:len(name)] == name
+}
@@ -6,9 +6,21 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"regexp"
+	"sort"
 	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
 )
 
+// bugCommentRE matches the godoc BUG(who): convention used to populate a
+// package's "Bugs" section, e.g. "// BUG(jdoe): this leaks a goroutine."
+var bugCommentRE = regexp.MustCompile(`^/[/*][ \t]*BUG\(.*\):`)
+
+// bugPragma is the magic comment that marks a function as needing a
+// synthesized BUG stub when -genBugs is set.
+const bugPragma = "//gocmt:bug"
+
 // parseFile parses and modifies the input file if necessary. Returns AST represents of (new) source, a boolean
 // to report whether the source file was modified, and any error if occurred.
 func parseFile(fset *token.FileSet, filePath, template string) (af *ast.File, modified bool, err error) {
@@ -33,22 +45,48 @@ func parseFile(fset *token.FileSet, filePath, template string) (af *ast.File, mo
 		originalCommentSign += c.Text()
 	}
 
+	// stripBugPragmas must run before ast.NewCommentMap: it mutates
+	// fd.Doc (and af.Comments) in place, and cmap associates a func's doc
+	// group with it by reference at construction time, so stripping the
+	// pragma any later would leave a stale, pragma-bearing group behind in
+	// cmap even though fd.Doc itself no longer carries it.
+	bugStubs := stripBugPragmas(af)
+
 	cmap := ast.NewCommentMap(fset, af, af.Comments)
+	if cmap == nil {
+		// stripBugPragmas can consume af's only comment group, and
+		// NewCommentMap returns a nil map (not an empty one) when there are
+		// no comments to associate; cmap is written into directly below, so
+		// it must be non-nil even when there's nothing in it yet.
+		cmap = make(ast.CommentMap)
+	}
 
-	skipped := make(map[ast.Node]bool)
-	ast.Inspect(af, func(n ast.Node) bool {
-		switch typ := n.(type) {
+	// Bug and directive comments are free-floating and aren't owned by any
+	// decl, so ast.CommentMap.Filter would otherwise drop them during the
+	// rewrite below.
+	bugGroups := append(findBugComments(af), bugStubs...)
+	directiveGroups := findDirectiveComments(af)
+
+	gen := newGenerator(commentTemplate)
+
+	// astutil.Apply's Cursor exposes the parent node directly, so a
+	// *ast.GenDecl nested in a *ast.DeclStmt (a function-body var/const/type
+	// declaration) can be skipped by looking at c.Parent() instead of
+	// threading a side-channel "skipped" map populated on an earlier visit.
+	astutil.Apply(af, func(c *astutil.Cursor) bool {
+		switch typ := c.Node().(type) {
 		case *ast.FuncDecl:
-			if skipped[typ] || !typ.Name.IsExported() {
+			if !typ.Name.IsExported() {
 				return true
 			}
-			addFuncDeclComment(typ, commentTemplate)
+			addFuncDeclComment(typ, gen, fset)
 			cmap[typ] = appendCommentGroup(cmap[typ], typ.Doc)
 
-		case *ast.DeclStmt:
-			skipped[typ.Decl] = true
-
 		case *ast.GenDecl:
+			if _, inFuncBody := c.Parent().(*ast.DeclStmt); inFuncBody {
+				return false
+			}
+
 			switch typ.Tok {
 			case token.CONST, token.VAR:
 				if !(typ.Lparen == token.NoPos && typ.Rparen == token.NoPos) {
@@ -59,7 +97,7 @@ func parseFile(fset *token.FileSet, filePath, template string) (af *ast.File, mo
 							if !vs.Names[0].IsExported() {
 								continue
 							}
-							addParenValueSpecComment(vs, commentTemplate)
+							addParenValueSpecComment(vs, gen, fset)
 							cmap[vs] = appendCommentGroup(cmap[vs], vs.Doc)
 						}
 						return true
@@ -72,27 +110,29 @@ func parseFile(fset *token.FileSet, filePath, template string) (af *ast.File, mo
 				}
 
 				vs := typ.Specs[0].(*ast.ValueSpec)
-				if skipped[typ] || !vs.Names[0].IsExported() {
+				if !vs.Names[0].IsExported() {
 					return true
 				}
-				addValueSpecComment(typ, vs, commentTemplate)
+				addValueSpecComment(typ, vs, gen, fset)
 
 			case token.TYPE:
 				ts := typ.Specs[0].(*ast.TypeSpec)
-				if skipped[typ] || !ts.Name.IsExported() {
+				if !ts.Name.IsExported() {
 					return true
 				}
-				addTypeSpecComment(typ, ts, commentTemplate)
+				addTypeSpecComment(typ, ts, gen, fset)
+				addFieldComments(ts, cmap, gen, fset)
 			default:
 				return true
 			}
 			cmap[typ] = appendCommentGroup(cmap[typ], typ.Doc)
 		}
 		return true
-	})
+	}, nil)
 
-	// Rebuild comments
-	af.Comments = cmap.Filter(af).Comments()
+	// Rebuild comments, then restore any BUG(...) and directive groups the
+	// filter dropped.
+	af.Comments = mergeComments(mergeComments(cmap.Filter(af).Comments(), bugGroups), directiveGroups)
 
 	currentCommentSign := ""
 	for _, c := range af.Comments {
@@ -103,9 +143,15 @@ func parseFile(fset *token.FileSet, filePath, template string) (af *ast.File, mo
 	return
 }
 
-func addFuncDeclComment(fd *ast.FuncDecl, commentTemplate string) {
+func addFuncDeclComment(fd *ast.FuncDecl, gen CommentGenerator, fset *token.FileSet) {
+	if isDirectiveComment(fd.Doc) {
+		return
+	}
 	if fd.Doc == nil || strings.TrimSpace(fd.Doc.Text()) == fd.Name.Name {
-		text := fmt.Sprintf(commentTemplate, fd.Name)
+		text, err := gen.Generate(KindFunc, fd.Name.Name, fd, fset)
+		if err != nil {
+			return
+		}
 		pos := fd.Pos() - token.Pos(1)
 		if fd.Doc != nil {
 			pos = fd.Doc.Pos()
@@ -114,14 +160,20 @@ func addFuncDeclComment(fd *ast.FuncDecl, commentTemplate string) {
 		return
 	}
 	if fd.Doc != nil && isLineComment(fd.Doc) && !hasCommentPrefix(fd.Doc, fd.Name.Name) {
-		modifyComment(fd.Doc, fd.Name.Name)
+		modifyComment(fd.Doc, KindFunc, fd.Name.Name, fd, gen, fset)
 		return
 	}
 }
 
-func addValueSpecComment(gd *ast.GenDecl, vs *ast.ValueSpec, commentTemplate string) {
+func addValueSpecComment(gd *ast.GenDecl, vs *ast.ValueSpec, gen CommentGenerator, fset *token.FileSet) {
+	if isDirectiveComment(gd.Doc) {
+		return
+	}
 	if gd.Doc == nil || strings.TrimSpace(gd.Doc.Text()) == vs.Names[0].Name {
-		text := fmt.Sprintf(commentTemplate, vs.Names[0].Name)
+		text, err := gen.Generate(KindValue, vs.Names[0].Name, gd, fset)
+		if err != nil {
+			return
+		}
 		pos := gd.Pos() - token.Pos(1)
 		if gd.Doc != nil {
 			pos = gd.Doc.Pos()
@@ -130,15 +182,24 @@ func addValueSpecComment(gd *ast.GenDecl, vs *ast.ValueSpec, commentTemplate str
 		return
 	}
 	if gd.Doc != nil && isLineComment(gd.Doc) && !hasCommentPrefix(gd.Doc, vs.Names[0].Name) {
-		modifyComment(gd.Doc, vs.Names[0].Name)
+		modifyComment(gd.Doc, KindValue, vs.Names[0].Name, gd, gen, fset)
 		return
 	}
 }
 
-func addParenValueSpecComment(vs *ast.ValueSpec, commentTemplate string) {
+// addParenValueSpecComment adds a doc comment to a single entry of a
+// parenthesized var/const block. It uses gen directly, same as every other
+// call site: go/printer already indents a ValueSpec's Doc to match its
+// block, so there's nothing for the caller to do about indentation here.
+func addParenValueSpecComment(vs *ast.ValueSpec, gen CommentGenerator, fset *token.FileSet) {
+	if isDirectiveComment(vs.Doc) {
+		return
+	}
 	if vs.Doc == nil || strings.TrimSpace(vs.Doc.Text()) == vs.Names[0].Name {
-		commentTemplate = strings.Replace(commentTemplate, commentBase, commentIndentedBase, 1)
-		text := fmt.Sprintf(commentTemplate, vs.Names[0].Name)
+		text, err := gen.Generate(KindValue, vs.Names[0].Name, vs, fset)
+		if err != nil {
+			return
+		}
 		pos := vs.Pos() - token.Pos(1)
 		if vs.Doc != nil {
 			pos = vs.Doc.Pos()
@@ -147,14 +208,20 @@ func addParenValueSpecComment(vs *ast.ValueSpec, commentTemplate string) {
 		return
 	}
 	if vs.Doc != nil && isLineComment(vs.Doc) && !hasCommentPrefix(vs.Doc, vs.Names[0].Name) {
-		modifyComment(vs.Doc, vs.Names[0].Name)
+		modifyComment(vs.Doc, KindValue, vs.Names[0].Name, vs, gen, fset)
 		return
 	}
 }
 
-func addTypeSpecComment(gd *ast.GenDecl, ts *ast.TypeSpec, commentTemplate string) {
+func addTypeSpecComment(gd *ast.GenDecl, ts *ast.TypeSpec, gen CommentGenerator, fset *token.FileSet) {
+	if isDirectiveComment(gd.Doc) {
+		return
+	}
 	if gd.Doc == nil || strings.TrimSpace(gd.Doc.Text()) == ts.Name.Name {
-		text := fmt.Sprintf(commentTemplate, ts.Name.Name)
+		text, err := gen.Generate(KindType, ts.Name.Name, gd, fset)
+		if err != nil {
+			return
+		}
 		pos := gd.Pos() - token.Pos(1)
 		if gd.Doc != nil {
 			pos = gd.Doc.Pos()
@@ -163,20 +230,218 @@ func addTypeSpecComment(gd *ast.GenDecl, ts *ast.TypeSpec, commentTemplate strin
 		return
 	}
 	if gd.Doc != nil && isLineComment(gd.Doc) && !hasCommentPrefix(gd.Doc, ts.Name.Name) {
-		modifyComment(gd.Doc, ts.Name.Name)
+		modifyComment(gd.Doc, KindType, ts.Name.Name, gd, gen, fset)
 		return
 	}
 }
 
-func modifyComment(comment *ast.CommentGroup, prefix string) {
-	commentTemplate := commentBase + *template
-	first := comment.List[0].Text
-	if strings.HasPrefix(first, "//") && !strings.HasPrefix(first, "// ") {
-		text := fmt.Sprintf(commentTemplate, prefix)
-		comment.List = append([]*ast.Comment{{Text: text, Slash: comment.Pos()}}, comment.List...)
+// addFieldComments walks the underlying type of ts and adds a doc comment to
+// each exported struct field (when -fields is set) or interface method (when
+// -methods is set), registering the result in cmap so go/printer interleaves
+// the comments at the right positions.
+func addFieldComments(ts *ast.TypeSpec, cmap ast.CommentMap, gen CommentGenerator, fset *token.FileSet) {
+	switch t := ts.Type.(type) {
+	case *ast.StructType:
+		if !*fieldsComment {
+			return
+		}
+		for _, field := range t.Fields.List {
+			if len(field.Names) == 0 || !field.Names[0].IsExported() {
+				continue
+			}
+			addStructFieldComment(field, gen, fset)
+			cmap[field] = appendCommentGroup(cmap[field], field.Doc)
+		}
+
+	case *ast.InterfaceType:
+		if !*methodsComment {
+			return
+		}
+		for _, method := range t.Methods.List {
+			if len(method.Names) == 0 || !method.Names[0].IsExported() {
+				continue
+			}
+			addInterfaceMethodComment(method, gen, fset)
+			cmap[method] = appendCommentGroup(cmap[method], method.Doc)
+		}
+	}
+}
+
+func addStructFieldComment(field *ast.Field, gen CommentGenerator, fset *token.FileSet) {
+	if isDirectiveComment(field.Doc) {
+		return
+	}
+	name := field.Names[0].Name
+	if field.Doc == nil || strings.TrimSpace(field.Doc.Text()) == name {
+		text, err := gen.Generate(KindValue, name, field, fset)
+		if err != nil {
+			return
+		}
+		pos := field.Pos() - token.Pos(1)
+		if field.Doc != nil {
+			pos = field.Doc.Pos()
+		}
+		field.Doc = &ast.CommentGroup{List: []*ast.Comment{{Slash: pos, Text: text}}}
+		return
+	}
+	if isLineComment(field.Doc) && !hasCommentPrefix(field.Doc, name) {
+		modifyComment(field.Doc, KindValue, name, field, gen, fset)
+	}
+}
+
+func addInterfaceMethodComment(method *ast.Field, gen CommentGenerator, fset *token.FileSet) {
+	if isDirectiveComment(method.Doc) {
+		return
+	}
+	name := method.Names[0].Name
+	if method.Doc == nil || strings.TrimSpace(method.Doc.Text()) == name {
+		text, err := gen.Generate(KindFunc, name, method, fset)
+		if err != nil {
+			return
+		}
+		pos := method.Pos() - token.Pos(1)
+		if method.Doc != nil {
+			pos = method.Doc.Pos()
+		}
+		method.Doc = &ast.CommentGroup{List: []*ast.Comment{{Slash: pos, Text: text}}}
+		return
+	}
+	if isLineComment(method.Doc) && !hasCommentPrefix(method.Doc, name) {
+		modifyComment(method.Doc, KindFunc, name, method, gen, fset)
+	}
+}
+
+// findBugComments returns the comment groups in af.Comments that document a
+// known bug via the godoc BUG(who): convention, so they can be restored after
+// cmap.Filter drops comments that aren't attached to a surviving decl.
+func findBugComments(af *ast.File) []*ast.CommentGroup {
+	var bugs []*ast.CommentGroup
+	for _, cg := range af.Comments {
+		if len(cg.List) > 0 && bugCommentRE.MatchString(cg.List[0].Text) {
+			bugs = append(bugs, cg)
+		}
+	}
+	return bugs
+}
+
+// mergeComments adds any group from extra not already present in comments
+// (by position) and returns the result sorted by position, as go/printer
+// expects. It's used to restore free-floating groups, such as BUG(who) and
+// build-directive comments, that ast.CommentMap.Filter would otherwise drop
+// because they aren't the Doc of any decl that survived the rewrite.
+func mergeComments(comments []*ast.CommentGroup, extra []*ast.CommentGroup) []*ast.CommentGroup {
+	if len(extra) == 0 {
+		return comments
+	}
+
+	present := make(map[token.Pos]bool, len(comments))
+	for _, cg := range comments {
+		present[cg.Pos()] = true
+	}
+	for _, cg := range extra {
+		if !present[cg.Pos()] {
+			comments = append(comments, cg)
+			present[cg.Pos()] = true
+		}
+	}
+
+	sort.Slice(comments, func(i, j int) bool { return comments[i].Pos() < comments[j].Pos() })
+	return comments
+}
+
+// hasBugPragma reports whether fd carries the "//gocmt:bug" magic comment
+// requesting a synthesized BUG stub.
+func hasBugPragma(fd *ast.FuncDecl) bool {
+	if fd.Doc == nil {
+		return false
+	}
+	for _, c := range fd.Doc.List {
+		if strings.TrimSpace(c.Text) == bugPragma {
+			return true
+		}
+	}
+	return false
+}
+
+// stripBugPragmas walks af for funcs carrying the "//gocmt:bug" pragma, when
+// -genBugs is set, stripping the pragma from each one's Doc and returning a
+// synthesized BUG stub per func. It must run before ast.NewCommentMap (see
+// the comment at its call site in parseFile), so it also drops any
+// now-empty comment group from af.Comments directly, rather than leaving an
+// orphaned group for NewCommentMap to associate with something else.
+func stripBugPragmas(af *ast.File) []*ast.CommentGroup {
+	if !*genBugs {
+		return nil
+	}
+
+	var stubs []*ast.CommentGroup
+	ast.Inspect(af, func(n ast.Node) bool {
+		fd, ok := n.(*ast.FuncDecl)
+		if !ok || !hasBugPragma(fd) {
+			return true
+		}
+		stubs = append(stubs, newBugStub(fd))
+		stripBugPragma(fd)
+		return true
+	})
+	if len(stubs) == 0 {
+		return nil
+	}
+
+	kept := af.Comments[:0]
+	for _, cg := range af.Comments {
+		if len(cg.List) > 0 {
+			kept = append(kept, cg)
+		}
+	}
+	af.Comments = kept
+	return stubs
+}
+
+// stripBugPragma removes the "//gocmt:bug" line from fd.Doc, clearing Doc
+// entirely if nothing else is left, so the pragma is consumed rather than
+// rendered verbatim (or fed to addFuncDeclComment's existing-doc path,
+// which would otherwise splice a stub comment into it). It always writes
+// back through fd.Doc.List, rather than only when something survives,
+// because af.Comments holds this same CommentGroup by reference: leaving
+// its List untouched in the all-pragma case would keep the pragma visible
+// to anything that still walks af.Comments looking at this group.
+func stripBugPragma(fd *ast.FuncDecl) {
+	if fd.Doc == nil {
+		return
+	}
+	kept := fd.Doc.List[:0]
+	for _, c := range fd.Doc.List {
+		if strings.TrimSpace(c.Text) != bugPragma {
+			kept = append(kept, c)
+		}
+	}
+	fd.Doc.List = kept
+	if len(kept) == 0 {
+		fd.Doc = nil
+	}
+}
+
+// newBugStub synthesizes a "// BUG(TODO): describe ..." comment group for fd.
+// Its position is placed ahead of fd itself (rather than derived from
+// fd.Doc, which stripBugPragma may have just cleared to nil) so it can never
+// fall inside fd's own token span and get spliced into the declaration by
+// the printer.
+func newBugStub(fd *ast.FuncDecl) *ast.CommentGroup {
+	text := fmt.Sprintf("// BUG(TODO): describe %s\n", fd.Name.Name)
+	return &ast.CommentGroup{List: []*ast.Comment{{Slash: fd.Pos() - token.Pos(2), Text: text}}}
+}
+
+// modifyComment fixes up an existing, user-written doc comment that doesn't
+// start with the required name prefix by prepending a line from gen, rather
+// than discarding what's already there. It goes through gen (not a hardcoded
+// template) so -signatureComments and shell-out generators apply here too,
+// since a missing name prefix on hand-written prose is the common case, not
+// the exception.
+func modifyComment(comment *ast.CommentGroup, kind NodeKind, name string, node ast.Node, gen CommentGenerator, fset *token.FileSet) {
+	text, err := gen.Generate(kind, name, node, fset)
+	if err != nil {
 		return
 	}
-	first = strings.TrimPrefix(first, "// ")
-	first = fmt.Sprintf(commentBase+"%s", prefix, first)
-	comment.List[0].Text = first
+	comment.List = append([]*ast.Comment{{Text: text, Slash: comment.Pos()}}, comment.List...)
 }
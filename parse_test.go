@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// render prints af back to source text for comparison.
+func render(t *testing.T, fset *token.FileSet, af *ast.File) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, af); err != nil {
+		t.Fatalf("printer.Fprint: %v", err)
+	}
+	return buf.String()
+}
+
+// writeTempFile writes src to a new file under t.TempDir() and returns its path.
+func writeTempFile(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// TestParseFileSkipsFuncScopeDecls verifies that exported-looking type and
+// const declarations nested inside a function body (via *ast.DeclStmt) are
+// left untouched by the astutil.Apply traversal, instead of being mistaken
+// for package-level declarations needing a doc stub.
+func TestParseFileSkipsFuncScopeDecls(t *testing.T) {
+	const src = `package testpkg
+
+// Foo does a thing.
+func Foo() {
+	type LocalType struct{ X int }
+	const LocalConst = 1
+	_ = LocalType{}
+	_ = LocalConst
+}
+`
+	path := writeTempFile(t, "p.go", src)
+	fset := token.NewFileSet()
+
+	af, modified, err := parseFile(fset, path, " does a thing.\n")
+	if err != nil {
+		t.Fatalf("parseFile: %v", err)
+	}
+	if modified {
+		t.Errorf("parseFile reported modified = true, want false (nothing here should be rewritten)")
+	}
+
+	out := render(t, fset, af)
+	if strings.Contains(out, "// LocalType") || strings.Contains(out, "// LocalConst") {
+		t.Errorf("parseFile added a doc stub to a function-scope decl, output:\n%s", out)
+	}
+}
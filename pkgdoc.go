@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+)
+
+// FileResult reports the outcome of running gocmt against a single file
+// within a package, mirroring the (af, modified) pair parseFile returns.
+type FileResult struct {
+	AF       *ast.File
+	Modified bool
+}
+
+// parseDir parses every Go file in dir and, unless some file already carries
+// a "// Package xxx ..." doc comment, injects one into the file whose name
+// matches the package name, or the first file alphabetically if none does.
+// Unlike parseFile, which only sees one file at a time, parseDir can tell
+// that a sibling file already documents the package and avoid duplicating
+// (or missing) the package doc comment.
+func parseDir(fset *token.FileSet, dir, template string) (map[string]*FileResult, error) {
+	pkgs, err := parser.ParseDir(fset, dir, nil, parser.ParseComments|parser.AllErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*FileResult)
+	for _, pkg := range pkgs {
+		merged := ast.MergePackageFiles(pkg, ast.FilterFuncDuplicates)
+		for name, af := range pkg.Files {
+			results[name] = &FileResult{AF: af}
+		}
+
+		if merged.Doc != nil {
+			// Some file in the package already documents it; leave it alone.
+			continue
+		}
+
+		path, af := choosePackageDocFile(pkg)
+		rewritten, err := addPackageDocComment(fset, path, af, pkg.Name, commentBase+template)
+		if err != nil {
+			return nil, err
+		}
+		results[path] = &FileResult{AF: rewritten, Modified: true}
+	}
+
+	return results, nil
+}
+
+// choosePackageDocFile picks the file that should carry the package doc
+// comment: the one named after the package, or the first alphabetically.
+func choosePackageDocFile(pkg *ast.Package) (string, *ast.File) {
+	names := make([]string, 0, len(pkg.Files))
+	for name := range pkg.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fileBaseName(name) == pkg.Name {
+			return name, pkg.Files[name]
+		}
+	}
+	return names[0], pkg.Files[names[0]]
+}
+
+// fileBaseName strips the directory and ".go" extension from path.
+func fileBaseName(path string) string {
+	base := path
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' || path[i] == '\\' {
+			base = path[i+1:]
+			break
+		}
+	}
+	if len(base) > 3 && base[len(base)-3:] == ".go" {
+		base = base[:len(base)-3]
+	}
+	return base
+}
+
+// addPackageDocComment returns a copy of af with a "// Package name ..." doc
+// comment injected immediately before the package clause, re-parsed from
+// path (af's original path) under fset.
+//
+// This can't be done by assigning af.Doc a synthesized token.Pos the way
+// addFuncDeclComment does for a func: that trick relies on there being a
+// valid position strictly before the target token, but when "package" is
+// literally the first byte of the file (the common case), the file's own
+// token-range floor *is* af.Package - there's no position left to place a
+// new leading comment at, and go/printer's comment-interleaving only ever
+// prints a comment before a token whose offset is strictly greater than the
+// comment's. Rendering af back to text and reparsing it with the comment
+// text prepended sidesteps the problem entirely: the new file's positions
+// are assigned fresh by the parser, so the comment and the package clause
+// never need to contend for the same offset.
+func addPackageDocComment(fset *token.FileSet, path string, af *ast.File, name, commentTemplate string) (*ast.File, error) {
+	text := fmt.Sprintf(commentTemplate, "Package "+name)
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, af); err != nil {
+		return nil, fmt.Errorf("addPackageDocComment: render %s: %w", path, err)
+	}
+
+	rewritten, err := parser.ParseFile(fset, path, text+buf.String(), parser.ParseComments|parser.AllErrors)
+	if err != nil {
+		return nil, fmt.Errorf("addPackageDocComment: reparse %s: %w", path, err)
+	}
+	return rewritten, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseDirInjectsPackageDocComment verifies that parseDir synthesizes a
+// "// Package name ..." comment on the package-named file, rendered
+// immediately before the package clause rather than spliced into it.
+func TestParseDirInjectsPackageDocComment(t *testing.T) {
+	dir := t.TempDir()
+	const src = `package testpkg
+
+func Foo() {}
+`
+	path := filepath.Join(dir, "testpkg.go")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	results, err := parseDir(fset, dir, " does a thing.\n")
+	if err != nil {
+		t.Fatalf("parseDir: %v", err)
+	}
+
+	result, ok := results[path]
+	if !ok {
+		t.Fatalf("parseDir returned no result for %s, got %v", path, results)
+	}
+	if !result.Modified {
+		t.Errorf("parseDir reported modified = false, want true")
+	}
+
+	out := render(t, fset, result.AF)
+	const want = "// Package testpkg does a thing.\npackage testpkg\n"
+	if !strings.HasPrefix(out, want) {
+		t.Errorf("parseDir did not render the package doc comment before the package clause, output:\n%s", out)
+	}
+}
+
+// TestParseDirSkipsExistingPackageDoc verifies that parseDir leaves a
+// package alone when one of its files already documents it.
+func TestParseDirSkipsExistingPackageDoc(t *testing.T) {
+	dir := t.TempDir()
+	const src = `// Package testpkg already has docs.
+package testpkg
+
+func Foo() {}
+`
+	path := filepath.Join(dir, "testpkg.go")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	results, err := parseDir(fset, dir, " does a thing.\n")
+	if err != nil {
+		t.Fatalf("parseDir: %v", err)
+	}
+
+	result, ok := results[path]
+	if !ok {
+		t.Fatalf("parseDir returned no result for %s, got %v", path, results)
+	}
+	if result.Modified {
+		t.Errorf("parseDir reported modified = true for a package that already has docs")
+	}
+}